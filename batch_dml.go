@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"cloud.google.com/go/spanner"
+)
+
+var (
+	startBatchDmlRe = regexp.MustCompile(`(?is)^\s*START\s+BATCH\s+DML\s*;?\s*$`)
+	runBatchRe      = regexp.MustCompile(`(?is)^\s*RUN\s+BATCH\s*;?\s*$`)
+	abortBatchRe    = regexp.MustCompile(`(?is)^\s*ABORT\s+BATCH\s*;?\s*$`)
+)
+
+// batchDmlContext buffers the statements issued between START BATCH DML and
+// RUN BATCH/ABORT BATCH.
+type batchDmlContext struct {
+	statements []spanner.Statement
+}
+
+// StartBatchDmlStatement opens DML batching on the session: subsequent DML
+// statements are buffered instead of executed until RUN BATCH or ABORT
+// BATCH is issued.
+type StartBatchDmlStatement struct{}
+
+func (s *StartBatchDmlStatement) Execute(session *Session) (*Result, error) {
+	if session.InBatch() {
+		return nil, fmt.Errorf("a batch is already in progress; issue RUN BATCH or ABORT BATCH first")
+	}
+	session.batch = &batchDmlContext{}
+	return &Result{ColumnNames: []string{}, Rows: []Row{}}, nil
+}
+
+// AbortBatchStatement discards the buffered statements without executing
+// them.
+type AbortBatchStatement struct{}
+
+func (s *AbortBatchStatement) Execute(session *Session) (*Result, error) {
+	if !session.InBatch() {
+		return nil, fmt.Errorf("no batch is in progress")
+	}
+	session.batch = nil
+	return &Result{ColumnNames: []string{}, Rows: []Row{}}, nil
+}
+
+// RunBatchStatement sends the buffered statements as a single
+// ExecuteBatchDml RPC inside a read-write transaction, then closes the
+// batch regardless of outcome. A failure partway through aborts the whole
+// transaction atomically — nothing from the batch is committed — while
+// still reporting the per-statement counts BatchUpdate captured before the
+// failure, so the caller can see exactly how far it got.
+type RunBatchStatement struct{}
+
+func (s *RunBatchStatement) Execute(session *Session) (*Result, error) {
+	if !session.InBatch() {
+		return nil, fmt.Errorf("no batch is in progress")
+	}
+	stmts := session.batch.statements
+	session.batch = nil
+
+	var counts []int64
+	_, txErr := session.client.ReadWriteTransaction(session.ctx, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
+		var err error
+		counts, err = txn.BatchUpdate(ctx, stmts)
+		// Returning the error aborts the transaction, so a partial
+		// failure never leaves the earlier statements committed; counts
+		// still holds what BatchUpdate reported for the statements that
+		// ran before the failure.
+		return err
+	})
+
+	rows := make([]Row, len(counts))
+	var total int64
+	for i, c := range counts {
+		rows[i] = Row{Columns: []string{fmt.Sprintf("%d", c)}}
+		total += c
+	}
+
+	result := &Result{
+		ColumnNames: []string{"AffectedRows"},
+		Rows:        rows,
+		Stats:       Stats{AffectedRows: int(total)},
+		IsMutation:  true,
+	}
+	if txErr != nil {
+		return result, fmt.Errorf("batch aborted after %d of %d statements (nothing committed): %w", len(counts), len(stmts), txErr)
+	}
+	return result, nil
+}
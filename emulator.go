@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	adminapi "cloud.google.com/go/spanner/admin/database/apiv1"
+	instanceapi "cloud.google.com/go/spanner/admin/instance/apiv1"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	adminpb "google.golang.org/genproto/googleapis/spanner/admin/database/v1"
+	instancepb "google.golang.org/genproto/googleapis/spanner/admin/instance/v1"
+)
+
+// emulatorClientOptions returns the client options needed to talk to the
+// Cloud Spanner Emulator at host instead of a real GCP endpoint.
+func emulatorClientOptions(host string) []option.ClientOption {
+	return []option.ClientOption{
+		option.WithEndpoint(host),
+		option.WithoutAuthentication(),
+		option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+	}
+}
+
+// ensureEmulatorDatabase creates instanceId and databaseId against the
+// emulator if they don't already exist. The emulator has no real billing
+// or config validation, so a minimal "emulator-config" instance config is
+// sufficient.
+func ensureEmulatorDatabase(ctx context.Context, projectId, instanceId, databaseId string, opts []option.ClientOption) error {
+	instanceAdmin, err := instanceapi.NewInstanceAdminClient(ctx, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to create instance admin client: %w", err)
+	}
+	defer instanceAdmin.Close()
+
+	instancePath := fmt.Sprintf("projects/%s/instances/%s", projectId, instanceId)
+	if _, err := instanceAdmin.GetInstance(ctx, &instancepb.GetInstanceRequest{Name: instancePath}); err != nil {
+		if status.Code(err) != codes.NotFound {
+			return fmt.Errorf("failed to look up emulator instance: %w", err)
+		}
+		op, err := instanceAdmin.CreateInstance(ctx, &instancepb.CreateInstanceRequest{
+			Parent:     fmt.Sprintf("projects/%s", projectId),
+			InstanceId: instanceId,
+			Instance: &instancepb.Instance{
+				Config:      fmt.Sprintf("projects/%s/instanceConfigs/emulator-config", projectId),
+				DisplayName: instanceId,
+				NodeCount:   1,
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create emulator instance: %w", err)
+		}
+		if _, err := op.Wait(ctx); err != nil {
+			return fmt.Errorf("failed to create emulator instance: %w", err)
+		}
+	}
+
+	databaseAdmin, err := adminapi.NewDatabaseAdminClient(ctx, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to create database admin client: %w", err)
+	}
+	defer databaseAdmin.Close()
+
+	dbPath := fmt.Sprintf("%s/databases/%s", instancePath, databaseId)
+	if _, err := databaseAdmin.GetDatabase(ctx, &adminpb.GetDatabaseRequest{Name: dbPath}); err != nil {
+		if status.Code(err) != codes.NotFound {
+			return fmt.Errorf("failed to look up emulator database: %w", err)
+		}
+		op, err := databaseAdmin.CreateDatabase(ctx, &adminpb.CreateDatabaseRequest{
+			Parent:          instancePath,
+			CreateStatement: fmt.Sprintf("CREATE DATABASE `%s`", databaseId),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create emulator database: %w", err)
+		}
+		if _, err := op.Wait(ctx); err != nil {
+			return fmt.Errorf("failed to create emulator database: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -10,11 +10,14 @@ import (
 	"time"
 
 	"cloud.google.com/go/spanner"
+	"cloud.google.com/go/spanner/spannertest"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 	adminpb "google.golang.org/genproto/googleapis/spanner/admin/database/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
 const (
@@ -22,19 +25,56 @@ const (
 	envTestInstanceId = "SPANNER_CLI_INTEGRATION_TEST_INSTANCE_ID"
 	envTestDatabaseId = "SPANNER_CLI_INTEGRATION_TEST_DATABASE_ID"
 	envTestCredential = "SPANNER_CLI_INTEGRATION_TEST_CREDENTIAL"
+
+	envEmulatorHost = "SPANNER_EMULATOR_HOST"
+)
+
+// testMode selects which Spanner backend the integration tests run
+// against, so the same test bodies exercise a real project, the Cloud
+// Spanner Emulator, or an ephemeral in-process spannertest server.
+type testMode int
+
+const (
+	modeRealProject testMode = iota
+	modeEmulator
+	modeInProcess
 )
 
 var (
 	skipIntegrateTest bool
+	mode              testMode
 
 	testProjectId  string
 	testInstanceId string
 	testDatabaseId string
 	testCredential string
 
+	// inProcessServer is the ephemeral spannertest server used by
+	// modeInProcess, started once for the whole test binary.
+	inProcessServer *spannertest.Server
+
 	tableIdCounter uint32
 )
 
+// featuresUnsupportedInProcess lists spanner-cli features known not to
+// work against the in-process spannertest server, which implements only a
+// subset of the real Cloud Spanner API (no query plans, no Partitioned DML,
+// no batch read-only transactions / partitioned queries). Subtests
+// exercising these call skipIfUnsupportedInProcess to skip cleanly under
+// modeInProcess instead of failing on an unimplemented RPC.
+var featuresUnsupportedInProcess = map[string]bool{
+	"partitioned_dml": true,
+	"partition_query": true,
+	"explain":         true,
+}
+
+func skipIfUnsupportedInProcess(t *testing.T, feature string) {
+	t.Helper()
+	if mode == modeInProcess && featuresUnsupportedInProcess[feature] {
+		t.Skipf("%q is not supported by the in-process spannertest server", feature)
+	}
+}
+
 type testTableSchema struct {
 	Id     int64 `spanner: "id"`
 	Active bool  `spanner: "active"`
@@ -42,19 +82,46 @@ type testTableSchema struct {
 
 func TestMain(m *testing.M) {
 	initialize()
-	os.Exit(m.Run())
+	code := m.Run()
+	if inProcessServer != nil {
+		inProcessServer.Close()
+	}
+	os.Exit(code)
 }
 
+// initialize picks the integration test backend, in priority order: a real
+// project (if the four SPANNER_CLI_INTEGRATION_TEST_* env vars are set),
+// the Cloud Spanner Emulator (if SPANNER_EMULATOR_HOST is set), or else an
+// ephemeral in-process spannertest server, so `go test` works out of the
+// box without any external Spanner project.
 func initialize() {
-	if os.Getenv(envTestProjectId) == "" || os.Getenv(envTestInstanceId) == "" || os.Getenv(envTestDatabaseId) == "" || os.Getenv(envTestCredential) == "" {
-		skipIntegrateTest = true
+	if os.Getenv(envTestProjectId) != "" && os.Getenv(envTestInstanceId) != "" && os.Getenv(envTestDatabaseId) != "" && os.Getenv(envTestCredential) != "" {
+		mode = modeRealProject
+		testProjectId = os.Getenv(envTestProjectId)
+		testInstanceId = os.Getenv(envTestInstanceId)
+		testDatabaseId = os.Getenv(envTestDatabaseId)
+		testCredential = os.Getenv(envTestCredential)
+		return
+	}
+
+	if os.Getenv(envEmulatorHost) != "" {
+		mode = modeEmulator
+		testProjectId = "spanner-cli-test-project"
+		testInstanceId = "spanner-cli-test-instance"
+		testDatabaseId = "spanner-cli-test-database"
 		return
 	}
 
-	testProjectId = os.Getenv(envTestProjectId)
-	testInstanceId = os.Getenv(envTestInstanceId)
-	testDatabaseId = os.Getenv(envTestDatabaseId)
-	testCredential = os.Getenv(envTestCredential)
+	srv, err := spannertest.NewServer("localhost:0")
+	if err != nil {
+		skipIntegrateTest = true
+		return
+	}
+	inProcessServer = srv
+	mode = modeInProcess
+	testProjectId = "spanner-cli-test-project"
+	testInstanceId = "spanner-cli-test-instance"
+	testDatabaseId = "spanner-cli-test-database"
 }
 
 func generateUniqueTableId() string {
@@ -63,15 +130,6 @@ func generateUniqueTableId() string {
 }
 
 func setup(t *testing.T, ctx context.Context, dmls []string) (*Session, string, func()) {
-	session, err := NewSession(ctx, testProjectId, testInstanceId, testDatabaseId, spanner.ClientConfig{
-		SessionPoolConfig: spanner.SessionPoolConfig{WriteSessions: 0.2},
-	}, option.WithCredentialsJSON([]byte(testCredential)))
-	if err != nil {
-		t.Fatalf("failed to create test session: err=%s", err)
-	}
-
-	dbPath := fmt.Sprintf("projects/%s/instances/%s/databases/%s", testProjectId, testInstanceId, testDatabaseId)
-
 	tableId := generateUniqueTableId()
 	tableSchema := fmt.Sprintf(`
 	CREATE TABLE %s (
@@ -80,15 +138,39 @@ func setup(t *testing.T, ctx context.Context, dmls []string) (*Session, string,
 	) PRIMARY KEY (id)
 	`, tableId)
 
-	op, err := session.adminClient.UpdateDatabaseDdl(ctx, &adminpb.UpdateDatabaseDdlRequest{
-		Database:   dbPath,
-		Statements: []string{tableSchema},
-	})
-	if err != nil {
-		t.Fatalf("failed to create table: err=%s", err)
-	}
-	if err := op.Wait(ctx); err != nil {
-		t.Fatalf("failed to create table: err=%s", err)
+	var session *Session
+	var err error
+	switch mode {
+	case modeInProcess:
+		session, err = NewSession(ctx, testProjectId, testInstanceId, testDatabaseId, spanner.ClientConfig{
+			SessionPoolConfig: spanner.SessionPoolConfig{WriteSessions: 0.2},
+		},
+			option.WithEndpoint(inProcessServer.Addr()),
+			option.WithoutAuthentication(),
+			option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+		)
+		if err != nil {
+			t.Fatalf("failed to create test session: err=%s", err)
+		}
+		if err := inProcessServer.UpdateDDL(tableSchema); err != nil {
+			t.Fatalf("failed to create table: err=%s", err)
+		}
+	case modeEmulator:
+		session, err = NewSession(ctx, testProjectId, testInstanceId, testDatabaseId, spanner.ClientConfig{
+			SessionPoolConfig: spanner.SessionPoolConfig{WriteSessions: 0.2},
+		})
+		if err != nil {
+			t.Fatalf("failed to create test session: err=%s", err)
+		}
+		applyDdlOrFatal(t, ctx, session, tableSchema)
+	default: // modeRealProject
+		session, err = NewSession(ctx, testProjectId, testInstanceId, testDatabaseId, spanner.ClientConfig{
+			SessionPoolConfig: spanner.SessionPoolConfig{WriteSessions: 0.2},
+		}, option.WithCredentialsJSON([]byte(testCredential)))
+		if err != nil {
+			t.Fatalf("failed to create test session: err=%s", err)
+		}
+		applyDdlOrFatal(t, ctx, session, tableSchema)
 	}
 
 	for _, dml := range dmls {
@@ -107,7 +189,13 @@ func setup(t *testing.T, ctx context.Context, dmls []string) (*Session, string,
 	}
 
 	tearDown := func() {
-		op, err = session.adminClient.UpdateDatabaseDdl(ctx, &adminpb.UpdateDatabaseDdlRequest{
+		if mode == modeInProcess {
+			// The ephemeral server is torn down once for the whole test
+			// binary in TestMain; no per-table cleanup is needed.
+			return
+		}
+		dbPath := session.DatabasePath()
+		op, err := session.adminClient.UpdateDatabaseDdl(ctx, &adminpb.UpdateDatabaseDdlRequest{
 			Database:   dbPath,
 			Statements: []string{fmt.Sprintf("DROP TABLE %s", tableId)},
 		})
@@ -121,6 +209,23 @@ func setup(t *testing.T, ctx context.Context, dmls []string) (*Session, string,
 	return session, tableId, tearDown
 }
 
+// applyDdlOrFatal runs tableSchema against session's admin client, used by
+// the real-project and emulator modes (the in-process mode applies DDL
+// directly against the spannertest server instead).
+func applyDdlOrFatal(t *testing.T, ctx context.Context, session *Session, tableSchema string) {
+	t.Helper()
+	op, err := session.adminClient.UpdateDatabaseDdl(ctx, &adminpb.UpdateDatabaseDdlRequest{
+		Database:   session.DatabasePath(),
+		Statements: []string{tableSchema},
+	})
+	if err != nil {
+		t.Fatalf("failed to create table: err=%s", err)
+	}
+	if err := op.Wait(ctx); err != nil {
+		t.Fatalf("failed to create table: err=%s", err)
+	}
+}
+
 func TestSelect(t *testing.T) {
 	t.Parallel()
 	if skipIntegrateTest {
@@ -140,7 +245,7 @@ func TestSelect(t *testing.T) {
 		t.Fatalf("invalid statement: error=%s", err)
 	}
 
-	result, err := stmt.Execute(session)
+	result, err := ExecuteStatement(stmt, session)
 	if err != nil {
 		t.Fatalf("unexpected error happened: %s", err)
 	}
@@ -182,7 +287,7 @@ func TestDml(t *testing.T) {
 		t.Fatalf("invalid statement: error=%s", err)
 	}
 
-	result, err := stmt.Execute(session)
+	result, err := ExecuteStatement(stmt, session)
 	if err != nil {
 		t.Errorf("unexpected error happened: %s", err)
 	}
@@ -230,3 +335,284 @@ func TestDml(t *testing.T) {
 		t.Errorf("diff: %s", cmp.Diff(gotStructs, expectedStructs))
 	}
 }
+
+func TestPartitionedDml(t *testing.T) {
+	t.Parallel()
+	if skipIntegrateTest {
+		t.Skip("Integration tests skipped")
+	}
+	skipIfUnsupportedInProcess(t, "partitioned_dml")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 180*time.Second)
+	defer cancel()
+
+	// Seed 5000 rows, half of them inactive, so the partitioned update
+	// touches 2500 rows. setup inserts the seed in one RW transaction, so
+	// the table stays within what a single RW transaction can write.
+	values := make([]string, 0, 5000)
+	for i := 1; i <= 5000; i++ {
+		active := "false"
+		if i%2 == 0 {
+			active = "true"
+		}
+		values = append(values, fmt.Sprintf("(%d, %s)", i, active))
+	}
+	session, tableId, tearDown := setup(t, ctx, []string{
+		"INSERT INTO [[TABLE]] (id, active) VALUES " + strings.Join(values, ", "),
+	})
+	defer tearDown()
+
+	stmt, err := BuildStatement(fmt.Sprintf("PARTITIONED UPDATE %s SET active = true WHERE active = false", tableId))
+	if err != nil {
+		t.Fatalf("invalid statement: error=%s", err)
+	}
+
+	result, err := ExecuteStatement(stmt, session)
+	if err != nil {
+		t.Fatalf("unexpected error happened: %s", err)
+	}
+
+	if !result.IsPartitioned {
+		t.Errorf("expected result to be marked as partitioned")
+	}
+	if !result.IsMutation {
+		t.Errorf("expected result to be marked as a mutation")
+	}
+	if result.Stats.AffectedRows <= 0 {
+		t.Errorf("expected a positive lower-bound affected row count, got %d", result.Stats.AffectedRows)
+	}
+}
+
+func TestTryPartitionedQuery(t *testing.T) {
+	t.Parallel()
+	if skipIntegrateTest {
+		t.Skip("Integration tests skipped")
+	}
+	skipIfUnsupportedInProcess(t, "partition_query")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 180*time.Second)
+	defer cancel()
+
+	session, tableId, tearDown := setup(t, ctx, []string{
+		"INSERT INTO [[TABLE]] (id, active) VALUES (1, true), (2, false), (3, true)",
+	})
+	defer tearDown()
+
+	query := fmt.Sprintf("SELECT id, active FROM %s", tableId)
+
+	nonPartitioned, err := BuildStatement(fmt.Sprintf("SELECT id, active FROM %s ORDER BY id ASC", tableId))
+	if err != nil {
+		t.Fatalf("invalid statement: error=%s", err)
+	}
+	wantResult, err := ExecuteStatement(nonPartitioned, session)
+	if err != nil {
+		t.Fatalf("unexpected error happened: %s", err)
+	}
+
+	partitioned, err := BuildStatement(fmt.Sprintf("TRY PARTITIONED QUERY '%s'", query))
+	if err != nil {
+		t.Fatalf("invalid statement: error=%s", err)
+	}
+	gotResult, err := ExecuteStatement(partitioned, session)
+	if err != nil {
+		t.Fatalf("unexpected error happened: %s", err)
+	}
+
+	if got, want := len(gotResult.Rows), len(wantResult.Rows); got != want {
+		t.Errorf("expected %d rows from the partitioned query, got %d", want, got)
+	}
+}
+
+func TestExplain(t *testing.T) {
+	t.Parallel()
+	if skipIntegrateTest {
+		t.Skip("Integration tests skipped")
+	}
+	skipIfUnsupportedInProcess(t, "explain")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 180*time.Second)
+	defer cancel()
+
+	session, tableId, tearDown := setup(t, ctx, []string{
+		"INSERT INTO [[TABLE]] (id, active) VALUES (1, true)",
+	})
+	defer tearDown()
+
+	stmt, err := BuildStatement(fmt.Sprintf("EXPLAIN SELECT id, active FROM %s", tableId))
+	if err != nil {
+		t.Fatalf("invalid statement: error=%s", err)
+	}
+	result, err := ExecuteStatement(stmt, session)
+	if err != nil {
+		t.Fatalf("unexpected error happened: %s", err)
+	}
+
+	if result.Stats.QueryPlan == nil {
+		t.Errorf("expected QueryPlan to be populated")
+	}
+	if len(result.Rows) == 0 {
+		t.Errorf("expected the rendered plan to have at least one row")
+	}
+	if result.Stats.AffectedRows != 0 {
+		t.Errorf("EXPLAIN must not execute the query, got AffectedRows=%d", result.Stats.AffectedRows)
+	}
+}
+
+func TestExplainAnalyze(t *testing.T) {
+	t.Parallel()
+	if skipIntegrateTest {
+		t.Skip("Integration tests skipped")
+	}
+	skipIfUnsupportedInProcess(t, "explain")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 180*time.Second)
+	defer cancel()
+
+	session, tableId, tearDown := setup(t, ctx, []string{
+		"INSERT INTO [[TABLE]] (id, active) VALUES (1, true), (2, false)",
+	})
+	defer tearDown()
+
+	stmt, err := BuildStatement(fmt.Sprintf("EXPLAIN ANALYZE SELECT id, active FROM %s", tableId))
+	if err != nil {
+		t.Fatalf("invalid statement: error=%s", err)
+	}
+	result, err := ExecuteStatement(stmt, session)
+	if err != nil {
+		t.Fatalf("unexpected error happened: %s", err)
+	}
+
+	if result.Stats.QueryPlan == nil {
+		t.Errorf("expected QueryPlan to be populated")
+	}
+	if result.Stats.QueryStats == nil {
+		t.Errorf("expected QueryStats to be populated by EXPLAIN ANALYZE")
+	}
+	if got, want := result.Stats.AffectedRows, 2; got != want {
+		t.Errorf("expected EXPLAIN ANALYZE to execute the query and count %d rows, got %d", want, got)
+	}
+}
+
+func TestBatchDml(t *testing.T) {
+	t.Parallel()
+	if skipIntegrateTest {
+		t.Skip("Integration tests skipped")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 180*time.Second)
+	defer cancel()
+
+	session, tableId, tearDown := setup(t, ctx, []string{
+		"INSERT INTO [[TABLE]] (id, active) VALUES (1, true)",
+	})
+	defer tearDown()
+
+	statements := []string{
+		"START BATCH DML",
+		fmt.Sprintf("INSERT INTO %s (id, active) VALUES (2, false)", tableId),
+		fmt.Sprintf("UPDATE %s SET active = true WHERE id = 2", tableId),
+		fmt.Sprintf("DELETE FROM %s WHERE id = 1", tableId),
+		"RUN BATCH",
+	}
+
+	var result *Result
+	for _, s := range statements {
+		stmt, err := BuildStatement(s)
+		if err != nil {
+			t.Fatalf("invalid statement %q: error=%s", s, err)
+		}
+		result, err = ExecuteStatement(stmt, session)
+		if err != nil {
+			t.Fatalf("unexpected error executing %q: %s", s, err)
+		}
+	}
+
+	if got, want := len(result.Rows), 3; got != want {
+		t.Fatalf("expected %d per-statement rows, got %d", want, got)
+	}
+	if got, want := result.Stats.AffectedRows, 3; got != want {
+		t.Errorf("expected total affected rows %d, got %d", want, got)
+	}
+
+	query := spanner.NewStatement(fmt.Sprintf("SELECT id, active FROM %s ORDER BY id ASC", tableId))
+	iter := session.client.Single().Query(ctx, query)
+	defer iter.Stop()
+	gotStructs := make([]testTableSchema, 0)
+	for {
+		row, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		var got testTableSchema
+		if err := row.ToStruct(&got); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		gotStructs = append(gotStructs, got)
+	}
+	expectedStructs := []testTableSchema{
+		{2, true},
+	}
+	if !cmp.Equal(gotStructs, expectedStructs) {
+		t.Errorf("diff: %s", cmp.Diff(gotStructs, expectedStructs))
+	}
+}
+
+func TestBatchDmlPartialFailure(t *testing.T) {
+	t.Parallel()
+	if skipIntegrateTest {
+		t.Skip("Integration tests skipped")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 180*time.Second)
+	defer cancel()
+
+	session, tableId, tearDown := setup(t, ctx, []string{
+		"INSERT INTO [[TABLE]] (id, active) VALUES (1, true)",
+	})
+	defer tearDown()
+
+	statements := []string{
+		"START BATCH DML",
+		fmt.Sprintf("INSERT INTO %s (id, active) VALUES (2, false)", tableId),
+		// Duplicate primary key: this statement fails, which must abort
+		// the whole batch rather than leaving id=2 committed.
+		fmt.Sprintf("INSERT INTO %s (id, active) VALUES (1, false)", tableId),
+		"RUN BATCH",
+	}
+
+	var result *Result
+	var runErr error
+	for _, s := range statements {
+		stmt, err := BuildStatement(s)
+		if err != nil {
+			t.Fatalf("invalid statement %q: error=%s", s, err)
+		}
+		result, runErr = ExecuteStatement(stmt, session)
+		if s != "RUN BATCH" && runErr != nil {
+			t.Fatalf("unexpected error executing %q: %s", s, runErr)
+		}
+	}
+
+	if runErr == nil {
+		t.Fatalf("expected RUN BATCH to report the partial failure")
+	}
+	if result == nil {
+		t.Fatalf("expected a Result with the partial per-statement counts alongside the error")
+	}
+	if got, want := len(result.Rows), 1; got != want {
+		t.Fatalf("expected %d per-statement row for the statement that ran before the failure, got %d", want, got)
+	}
+
+	// The transaction must have aborted atomically: id=2's INSERT
+	// succeeded inside BatchUpdate, but nothing from the batch may be
+	// committed once a later statement in it fails.
+	query := spanner.NewStatement(fmt.Sprintf("SELECT id FROM %s WHERE id = 2", tableId))
+	iter := session.client.Single().Query(ctx, query)
+	defer iter.Stop()
+	if _, err := iter.Next(); err != iterator.Done {
+		t.Errorf("expected no rows committed by the aborted batch, got err=%v", err)
+	}
+}
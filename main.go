@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+)
+
+func main() {
+	projectId := flag.String("project", "", "GCP project id")
+	instanceId := flag.String("instance", "", "Cloud Spanner instance id")
+	databaseId := flag.String("database", "", "Cloud Spanner database id")
+	flag.Parse()
+
+	if *projectId == "" || *instanceId == "" || *databaseId == "" {
+		fmt.Fprintln(os.Stderr, "spanner-cli: -project, -instance and -database are required")
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	session, err := NewSession(ctx, *projectId, *instanceId, *databaseId, spanner.ClientConfig{})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "spanner-cli: failed to create session: %s\n", err)
+		os.Exit(1)
+	}
+	defer session.Close()
+
+	runREPL(session, os.Stdin, os.Stdout)
+}
+
+// runREPL reads one statement per line from in, executes it against
+// session via ExecuteStatement (so a backend Session-not-found error is
+// transparently retried), and prints the result to out.
+func runREPL(session *Session, in io.Reader, out io.Writer) {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		stmt, err := BuildStatement(line)
+		if err != nil {
+			fmt.Fprintf(out, "ERROR: %s\n", err)
+			continue
+		}
+
+		result, err := ExecuteStatement(stmt, session)
+		// A Result can be non-nil alongside an error (e.g. a partially
+		// failed batch reporting the counts of statements that ran
+		// before it aborted), so render it before surfacing the error.
+		if result != nil {
+			printResult(out, result)
+		}
+		if err != nil {
+			fmt.Fprintf(out, "ERROR: %s\n", err)
+			continue
+		}
+	}
+}
+
+func printResult(out io.Writer, result *Result) {
+	if len(result.ColumnNames) > 0 {
+		fmt.Fprintln(out, strings.Join(result.ColumnNames, "\t"))
+	}
+	for _, row := range result.Rows {
+		fmt.Fprintln(out, strings.Join(row.Columns, "\t"))
+	}
+	fmt.Fprintf(out, "(%d affected)\n", result.Stats.AffectedRows)
+}
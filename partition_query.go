@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"sync"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/api/iterator"
+)
+
+var (
+	partitionRe             = regexp.MustCompile(`(?is)^\s*PARTITION\s+'(.+)'\s*;?\s*$`)
+	tryPartitionedQueryRe   = regexp.MustCompile(`(?is)^\s*TRY\s+PARTITIONED\s+QUERY\s+'(.+)'\s*;?\s*$`)
+	setDataBoostRe          = regexp.MustCompile(`(?is)^\s*SET\s+DATA_BOOST\s*=\s*(TRUE|FALSE)\s*;?\s*$`)
+	setMaxPartitionsRe      = regexp.MustCompile(`(?is)^\s*SET\s+MAX_PARTITIONS\s*=\s*([0-9]+)\s*;?\s*$`)
+	defaultPartitionWorkers = 8
+)
+
+// PartitionStatement partitions a query via a Batch Read-Only Transaction
+// and returns the resulting partition tokens as rows, without executing
+// any of them. It is primarily a diagnostic tool for inspecting how a
+// query would be split for parallel export.
+type PartitionStatement struct {
+	Query string
+}
+
+func (s *PartitionStatement) Execute(session *Session) (*Result, error) {
+	ctx := session.ctx
+	txn, err := session.client.BatchReadOnlyTransaction(ctx, spanner.StrongRead())
+	if err != nil {
+		return nil, fmt.Errorf("failed to start batch read-only transaction: %w", err)
+	}
+	defer txn.Cleanup(ctx)
+
+	partitions, err := txn.PartitionQuery(ctx, spanner.NewStatement(s.Query), spanner.PartitionOptions{
+		MaxPartitions:    int64(session.maxPartitions),
+		DataBoostEnabled: session.dataBoost,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to partition query: %w", err)
+	}
+
+	rows := make([]Row, len(partitions))
+	for i, p := range partitions {
+		token, err := p.MarshalBinary()
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal partition token: %w", err)
+		}
+		rows[i] = Row{Columns: []string{base64.StdEncoding.EncodeToString(token)}}
+	}
+
+	return &Result{
+		ColumnNames: []string{"PartitionToken"},
+		Rows:        rows,
+		Stats:       Stats{AffectedRows: len(rows)},
+	}, nil
+}
+
+// TryPartitionedQueryStatement runs a query split into partitions and
+// fetched concurrently by a bounded worker pool, merging the results as if
+// it were a single SELECT. It is useful for quickly sanity-checking how a
+// large export query behaves when partitioned.
+type TryPartitionedQueryStatement struct {
+	Query string
+}
+
+func (s *TryPartitionedQueryStatement) Execute(session *Session) (*Result, error) {
+	ctx := session.ctx
+	txn, err := session.client.BatchReadOnlyTransaction(ctx, spanner.StrongRead())
+	if err != nil {
+		return nil, fmt.Errorf("failed to start batch read-only transaction: %w", err)
+	}
+	defer txn.Cleanup(ctx)
+
+	partitions, err := txn.PartitionQuery(ctx, spanner.NewStatement(s.Query), spanner.PartitionOptions{
+		MaxPartitions:    int64(session.maxPartitions),
+		DataBoostEnabled: session.dataBoost,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to partition query: %w", err)
+	}
+
+	workers := defaultPartitionWorkers
+	if len(partitions) < workers {
+		workers = len(partitions)
+	}
+	if workers == 0 {
+		workers = 1
+	}
+
+	partitionCh := make(chan *spanner.Partition)
+	rowsCh := make(chan []Row, len(partitions))
+	errCh := make(chan error, len(partitions))
+	columnNamesCh := make(chan []string, 1)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range partitionCh {
+				rows, columnNames, err := s.executePartition(ctx, txn, p)
+				if err != nil {
+					errCh <- err
+					continue
+				}
+				// Only a partition that actually produced rows knows the
+				// real column names; an empty partition must not pin the
+				// channel to nil and hide a non-empty partition's header.
+				if columnNames != nil {
+					select {
+					case columnNamesCh <- columnNames:
+					default:
+					}
+				}
+				rowsCh <- rows
+			}
+		}()
+	}
+
+	for _, p := range partitions {
+		partitionCh <- p
+	}
+	close(partitionCh)
+	wg.Wait()
+	close(rowsCh)
+	close(errCh)
+
+	if err := <-errCh; err != nil {
+		return nil, err
+	}
+
+	result := &Result{Rows: []Row{}}
+	select {
+	case result.ColumnNames = <-columnNamesCh:
+	default:
+		result.ColumnNames = []string{}
+	}
+	for rows := range rowsCh {
+		result.Rows = append(result.Rows, rows...)
+	}
+	result.Stats = Stats{AffectedRows: len(result.Rows)}
+	return result, nil
+}
+
+func (s *TryPartitionedQueryStatement) executePartition(ctx context.Context, txn *spanner.BatchReadOnlyTransaction, p *spanner.Partition) ([]Row, []string, error) {
+	iter := txn.Execute(ctx, p)
+	defer iter.Stop()
+
+	var columnNames []string
+	rows := []Row{}
+	for {
+		row, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if columnNames == nil {
+			columnNames = row.ColumnNames()
+		}
+		cols := make([]string, len(columnNames))
+		for i := range cols {
+			var v spanner.GenericColumnValue
+			if err := row.Column(i, &v); err != nil {
+				return nil, nil, err
+			}
+			cols[i] = fmt.Sprintf("%v", v.Value)
+		}
+		rows = append(rows, Row{Columns: cols})
+	}
+	return rows, columnNames, nil
+}
+
+// SetDataBoostStatement toggles use of Data Boost for subsequent
+// partitioned queries on this session.
+type SetDataBoostStatement struct {
+	Enabled bool
+}
+
+func (s *SetDataBoostStatement) Execute(session *Session) (*Result, error) {
+	session.dataBoost = s.Enabled
+	return &Result{ColumnNames: []string{}, Rows: []Row{}}, nil
+}
+
+// SetMaxPartitionsStatement configures the partition count hint used by
+// subsequent PARTITION/TRY PARTITIONED QUERY statements on this session.
+type SetMaxPartitionsStatement struct {
+	MaxPartitions int
+}
+
+func (s *SetMaxPartitionsStatement) Execute(session *Session) (*Result, error) {
+	session.maxPartitions = s.MaxPartitions
+	return &Result{ColumnNames: []string{}, Rows: []Row{}}, nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"regexp"
+
+	"cloud.google.com/go/spanner"
+)
+
+// partitionedDmlRe matches the PARTITIONED UPDATE/DELETE prefix used to
+// opt a single DML statement into Partitioned DML execution.
+var partitionedDmlRe = regexp.MustCompile(`(?is)^\s*PARTITIONED\s+(UPDATE|DELETE)\s`)
+
+// PartitionedDmlStatement executes a DML statement via PartitionedUpdate
+// instead of a normal read-write transaction. Partitioned DML runs outside
+// a single transaction, is idempotent-eligible, and only yields a
+// lower-bound affected row count.
+type PartitionedDmlStatement struct {
+	Dml string
+}
+
+func (s *PartitionedDmlStatement) Execute(session *Session) (*Result, error) {
+	count, err := session.client.PartitionedUpdate(session.ctx, spanner.NewStatement(s.Dml))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Result{
+		ColumnNames: []string{},
+		Rows:        []Row{},
+		Stats: Stats{
+			AffectedRows: int(count),
+		},
+		IsMutation:    true,
+		IsPartitioned: true,
+	}, nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// sessionResourceType is the ResourceInfo.ResourceType gRPC attaches to a
+// NotFound error for a Spanner session the backend has since deleted.
+const sessionResourceType = "type.googleapis.com/google.spanner.v1.Session"
+
+// isSessionNotFoundError reports whether err is a NotFound error for a
+// deleted Spanner session, identified via the status's ResourceInfo detail
+// rather than by matching the error message string.
+func isSessionNotFoundError(err error) bool {
+	st, ok := status.FromError(err)
+	if !ok || st.Code() != codes.NotFound {
+		return false
+	}
+	for _, d := range st.Details() {
+		if info, ok := d.(*errdetails.ResourceInfo); ok && info.ResourceType == sessionResourceType {
+			return true
+		}
+	}
+	return false
+}
+
+// ExecuteStatement runs stmt against session and transparently recovers
+// from a "Session not found" error: outside of a user transaction, it
+// recreates the session's client and retries the statement once; while a
+// user transaction is open (see InTransaction), its state is dropped and the
+// caller is told to re-run it, since the in-flight transaction state is gone.
+func ExecuteStatement(stmt Statement, session *Session) (*Result, error) {
+	result, err := stmt.Execute(session)
+	if err == nil || !isSessionNotFoundError(err) {
+		return result, err
+	}
+
+	if session.InTransaction() {
+		session.tc = nil
+		return nil, fmt.Errorf("the Spanner session backing this transaction was deleted by the backend; please re-run the transaction from BEGIN")
+	}
+
+	if err := session.recreateClient(); err != nil {
+		return nil, fmt.Errorf("session was deleted and could not be recreated: %w", err)
+	}
+	return stmt.Execute(session)
+}
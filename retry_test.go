@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	"cloud.google.com/go/spanner/spannertest"
+	"google.golang.org/api/option"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+func TestIsSessionNotFoundError(t *testing.T) {
+	sessionNotFound, err := status.New(codes.NotFound, "Session not found").WithDetails(
+		&errdetails.ResourceInfo{
+			ResourceType: sessionResourceType,
+			ResourceName: "projects/p/instances/i/databases/d/sessions/s",
+		},
+	)
+	if err != nil {
+		t.Fatalf("failed to build fabricated status: %s", err)
+	}
+
+	tableNotFound, err := status.New(codes.NotFound, "Table not found").WithDetails(
+		&errdetails.ResourceInfo{
+			ResourceType: "type.googleapis.com/google.spanner.v1.Table",
+			ResourceName: "projects/p/instances/i/databases/d/tables/t",
+		},
+	)
+	if err != nil {
+		t.Fatalf("failed to build fabricated status: %s", err)
+	}
+
+	for _, tt := range []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"session not found", sessionNotFound.Err(), true},
+		{"other NotFound resource", tableNotFound.Err(), false},
+		{"plain NotFound without details", status.New(codes.NotFound, "Session not found").Err(), false},
+		{"non-status error", errors.New("boom"), false},
+		{"nil error", nil, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isSessionNotFoundError(tt.err); got != tt.want {
+				t.Errorf("isSessionNotFoundError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeStatement fails with a fixed error on its first Execute call and
+// succeeds on every call after, so ExecuteStatement's retry path can be
+// exercised without a real Spanner backend returning the error.
+type fakeStatement struct {
+	failWith error
+	calls    int
+}
+
+func (s *fakeStatement) Execute(session *Session) (*Result, error) {
+	s.calls++
+	if s.calls == 1 {
+		return nil, s.failWith
+	}
+	return &Result{ColumnNames: []string{}, Rows: []Row{}}, nil
+}
+
+func TestExecuteStatementRetriesOnSessionNotFound(t *testing.T) {
+	srv, err := spannertest.NewServer("localhost:0")
+	if err != nil {
+		t.Fatalf("failed to start in-process spanner server: %s", err)
+	}
+	defer srv.Close()
+
+	ctx := context.Background()
+	session, err := NewSession(ctx, "fake-project", "fake-instance", "fake-database", spanner.ClientConfig{},
+		option.WithEndpoint(srv.Addr()),
+		option.WithoutAuthentication(),
+		option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+	)
+	if err != nil {
+		t.Fatalf("failed to create session: %s", err)
+	}
+	defer session.Close()
+
+	originalClient := session.client
+
+	sessionNotFound, err := status.New(codes.NotFound, "Session not found").WithDetails(
+		&errdetails.ResourceInfo{
+			ResourceType: sessionResourceType,
+			ResourceName: "projects/fake-project/instances/fake-instance/databases/fake-database/sessions/s",
+		},
+	)
+	if err != nil {
+		t.Fatalf("failed to build fabricated status: %s", err)
+	}
+
+	stmt := &fakeStatement{failWith: sessionNotFound.Err()}
+	result, err := ExecuteStatement(stmt, session)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if result == nil {
+		t.Fatalf("expected a result from the retried execution")
+	}
+	if stmt.calls != 2 {
+		t.Errorf("expected the statement to be executed twice (initial attempt + one retry), got %d", stmt.calls)
+	}
+	if session.client == originalClient {
+		t.Errorf("expected the session's client to be recreated after a Session-not-found error")
+	}
+}
+
+func TestExecuteStatementAbortsTransactionOnSessionNotFound(t *testing.T) {
+	session := &Session{tc: &transactionContext{}}
+
+	sessionNotFound, err := status.New(codes.NotFound, "Session not found").WithDetails(
+		&errdetails.ResourceInfo{ResourceType: sessionResourceType, ResourceName: "s"},
+	)
+	if err != nil {
+		t.Fatalf("failed to build fabricated status: %s", err)
+	}
+
+	stmt := &fakeStatement{failWith: sessionNotFound.Err()}
+	if _, err := ExecuteStatement(stmt, session); err == nil {
+		t.Fatalf("expected an error when the session is deleted mid-transaction")
+	}
+	if stmt.calls != 1 {
+		t.Errorf("expected no retry while a transaction is open, got %d calls", stmt.calls)
+	}
+	if session.InTransaction() {
+		t.Errorf("expected the aborted transaction state to be cleared")
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"cloud.google.com/go/spanner"
+	adminapi "cloud.google.com/go/spanner/admin/database/apiv1"
+	"google.golang.org/api/option"
+)
+
+// Session wraps a Spanner database client and admin client, plus the state
+// of any open user read-write transaction (tc).
+type Session struct {
+	ctx context.Context
+
+	projectId  string
+	instanceId string
+	databaseId string
+
+	client       *spanner.Client
+	adminClient  *adminapi.DatabaseAdminClient
+	clientConfig spanner.ClientConfig
+	clientOpts   []option.ClientOption
+
+	tc *transactionContext
+
+	// batch holds the buffered statements of an in-progress
+	// START BATCH DML / RUN BATCH / ABORT BATCH sequence, or nil when no
+	// batch is open.
+	batch *batchDmlContext
+
+	// dataBoost and maxPartitions configure subsequent PARTITION /
+	// TRY PARTITIONED QUERY statements, set via SET DATA_BOOST and
+	// SET MAX_PARTITIONS.
+	dataBoost     bool
+	maxPartitions int
+}
+
+// transactionContext holds the state of an open user read-write
+// transaction. None of the statements BuildStatement returns opens one.
+type transactionContext struct {
+	transaction *spanner.ReadWriteStmtBasedTransaction
+}
+
+// NewSession creates a Session connected to the given Spanner database. If
+// the SPANNER_EMULATOR_HOST environment variable is set, it connects to the
+// emulator instead and creates the instance/database there if they don't
+// already exist, so local development doesn't require a real GCP project.
+func NewSession(ctx context.Context, projectId, instanceId, databaseId string, clientConfig spanner.ClientConfig, opts ...option.ClientOption) (*Session, error) {
+	dbPath := fmt.Sprintf("projects/%s/instances/%s/databases/%s", projectId, instanceId, databaseId)
+
+	if emulatorHost := os.Getenv("SPANNER_EMULATOR_HOST"); emulatorHost != "" {
+		opts = append(opts, emulatorClientOptions(emulatorHost)...)
+		if err := ensureEmulatorDatabase(ctx, projectId, instanceId, databaseId, opts); err != nil {
+			return nil, fmt.Errorf("failed to provision emulator instance/database: %w", err)
+		}
+	}
+
+	client, err := spanner.NewClientWithConfig(ctx, dbPath, clientConfig, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create spanner client: %w", err)
+	}
+
+	adminClient, err := adminapi.NewDatabaseAdminClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create database admin client: %w", err)
+	}
+
+	return &Session{
+		ctx:          ctx,
+		projectId:    projectId,
+		instanceId:   instanceId,
+		databaseId:   databaseId,
+		client:       client,
+		adminClient:  adminClient,
+		clientConfig: clientConfig,
+		clientOpts:   opts,
+	}, nil
+}
+
+// DatabasePath returns the fully-qualified database path this session is
+// connected to.
+func (s *Session) DatabasePath() string {
+	return fmt.Sprintf("projects/%s/instances/%s/databases/%s", s.projectId, s.instanceId, s.databaseId)
+}
+
+// InTransaction reports whether a user-initiated read-write transaction is
+// currently open on this session.
+func (s *Session) InTransaction() bool {
+	return s.tc != nil
+}
+
+// InBatch reports whether a START BATCH DML ... RUN BATCH/ABORT BATCH
+// sequence is currently open on this session.
+func (s *Session) InBatch() bool {
+	return s.batch != nil
+}
+
+// recreateClient discards the session's current Spanner client and opens a
+// fresh one against the same database, for recovering from a backend
+// session deletion (see isSessionNotFoundError).
+func (s *Session) recreateClient() error {
+	client, err := spanner.NewClientWithConfig(s.ctx, s.DatabasePath(), s.clientConfig, s.clientOpts...)
+	if err != nil {
+		return fmt.Errorf("failed to recreate spanner client: %w", err)
+	}
+	s.client.Close()
+	s.client = client
+	return nil
+}
+
+// Close releases the resources held by the session's clients.
+func (s *Session) Close() {
+	s.client.Close()
+	s.adminClient.Close()
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/api/iterator"
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+)
+
+// Statement is a parsed, directly-executable spanner-cli statement.
+type Statement interface {
+	Execute(session *Session) (*Result, error)
+}
+
+// Row is a single result row rendered as strings, ready for display.
+type Row struct {
+	Columns []string
+}
+
+// Stats carries the metadata printed alongside a statement's results.
+type Stats struct {
+	AffectedRows int
+	ElapsedTime  string
+
+	// QueryPlan is populated by EXPLAIN and EXPLAIN ANALYZE.
+	QueryPlan *sppb.QueryPlan
+
+	// QueryStats carries the per-operator execution stats populated by
+	// EXPLAIN ANALYZE (rows returned, latency, CPU time, ...). It is nil
+	// for a plain EXPLAIN, which does not execute the query.
+	QueryStats map[string]interface{}
+}
+
+// Result is the outcome of executing a Statement.
+type Result struct {
+	ColumnNames []string
+	Rows        []Row
+	Stats       Stats
+	IsMutation  bool
+
+	// IsPartitioned is true when the statement ran as Partitioned DML,
+	// so AffectedRows is only a lower bound.
+	IsPartitioned bool
+}
+
+var (
+	selectRe = regexp.MustCompile(`(?is)^\s*(SELECT|WITH)\s`)
+)
+
+// BuildStatement parses input into an executable Statement.
+func BuildStatement(input string) (Statement, error) {
+	switch {
+	case explainAnalyzeRe.MatchString(input):
+		return &ExplainAnalyzeStatement{Query: explainAnalyzeRe.FindStringSubmatch(input)[1]}, nil
+	case explainRe.MatchString(input):
+		return &ExplainStatement{Query: explainRe.FindStringSubmatch(input)[1]}, nil
+	case selectRe.MatchString(input):
+		return &SelectStatement{Query: input}, nil
+	case partitionedDmlRe.MatchString(input):
+		return &PartitionedDmlStatement{Dml: partitionedDmlRe.ReplaceAllString(input, "$1 ")}, nil
+	case startBatchDmlRe.MatchString(input):
+		return &StartBatchDmlStatement{}, nil
+	case runBatchRe.MatchString(input):
+		return &RunBatchStatement{}, nil
+	case abortBatchRe.MatchString(input):
+		return &AbortBatchStatement{}, nil
+	case partitionRe.MatchString(input):
+		m := partitionRe.FindStringSubmatch(input)
+		return &PartitionStatement{Query: m[1]}, nil
+	case tryPartitionedQueryRe.MatchString(input):
+		m := tryPartitionedQueryRe.FindStringSubmatch(input)
+		return &TryPartitionedQueryStatement{Query: m[1]}, nil
+	case setDataBoostRe.MatchString(input):
+		m := setDataBoostRe.FindStringSubmatch(input)
+		return &SetDataBoostStatement{Enabled: strings.EqualFold(m[1], "TRUE")}, nil
+	case setMaxPartitionsRe.MatchString(input):
+		m := setMaxPartitionsRe.FindStringSubmatch(input)
+		n, err := strconv.Atoi(m[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid MAX_PARTITIONS value: %s", m[1])
+		}
+		return &SetMaxPartitionsStatement{MaxPartitions: n}, nil
+	default:
+		return &DmlStatement{Dml: input}, nil
+	}
+}
+
+// SelectStatement is a read-only query executed against a single-use
+// snapshot read transaction.
+type SelectStatement struct {
+	Query string
+}
+
+func (s *SelectStatement) Execute(session *Session) (*Result, error) {
+	stmt := spanner.NewStatement(s.Query)
+	iter := session.client.Single().Query(session.ctx, stmt)
+	defer iter.Stop()
+
+	result := &Result{Rows: []Row{}}
+	rowCount := 0
+	for {
+		row, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if rowCount == 0 {
+			result.ColumnNames = row.ColumnNames()
+		}
+		cols := make([]string, len(result.ColumnNames))
+		for i := range cols {
+			var v spanner.GenericColumnValue
+			if err := row.Column(i, &v); err != nil {
+				return nil, err
+			}
+			cols[i] = fmt.Sprintf("%v", v.Value)
+		}
+		result.Rows = append(result.Rows, Row{Columns: cols})
+		rowCount++
+	}
+
+	result.Stats = Stats{AffectedRows: rowCount}
+	return result, nil
+}
+
+// DmlStatement executes a single DML statement inside a normal read-write
+// transaction.
+type DmlStatement struct {
+	Dml string
+}
+
+func (s *DmlStatement) Execute(session *Session) (*Result, error) {
+	if session.InBatch() {
+		session.batch.statements = append(session.batch.statements, spanner.NewStatement(s.Dml))
+		return &Result{
+			ColumnNames: []string{},
+			Rows:        []Row{},
+			Stats:       Stats{},
+			IsMutation:  true,
+		}, nil
+	}
+
+	var affectedRows int64
+	_, err := session.client.ReadWriteTransaction(session.ctx, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
+		n, err := txn.Update(ctx, spanner.NewStatement(s.Dml))
+		if err != nil {
+			return err
+		}
+		affectedRows = n
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Result{
+		ColumnNames: []string{},
+		Rows:        []Row{},
+		Stats:       Stats{AffectedRows: int(affectedRows)},
+		IsMutation:  true,
+	}, nil
+}